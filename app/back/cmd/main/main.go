@@ -21,10 +21,12 @@ func main() {
 	cfg := config.MustLoad()
 	var err error
 
-	if err = goose.InitMigrations(cfg.DBPostgres.DSN, cfg.DBPostgres.MigrationsPath); err != nil {
-		panic(errors.New("init migrations failed: " + err.Error()))
+	if cfg.DBPostgres.AutoMigrate {
+		if err = goose.InitMigrations(cfg.DBPostgres.DSNString(), cfg.DBPostgres.MigrationsPath); err != nil {
+			panic(errors.New("init migrations failed: " + err.Error()))
+		}
 	}
-	DB, err := postgres.InitDB(cfg.DBPostgres.DSN)
+	DB, err := postgres.InitDB(cfg.DBPostgres.DSNString())
 	if err != nil {
 		panic(errors.New("init db failed: " + err.Error()))
 	}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/TaskGroup/dbpsql/app/back/config"
+	"github.com/TaskGroup/dbpsql/app/back/pkg/migration/goose"
+	_ "github.com/lib/pq"
+)
+
+// cmd/migrate — отдельный бинарь для управления миграциями в обход автозапуска в main.go.
+// Пример: migrate -cmd up | migrate -cmd down | migrate -cmd status | migrate -cmd create -name add_users -kind sql
+func main() {
+	var (
+		cmd  = flag.String("cmd", "up", "команда миграции: up, up-to, down, down-to, redo, status, version, create")
+		name = flag.String("name", "", "имя новой миграции (для create)")
+		kind = flag.String("kind", "sql", "тип новой миграции: sql или go (для create)")
+		ver  = flag.Int64("version", 0, "версия миграции (для up-to, down-to)")
+	)
+	// Значение -config читает сам config.Load из os.Args; флаг регистрируется здесь только
+	// затем, чтобы flag.Parse не отвергал его как неизвестный
+	flag.String("config", "", "путь к yaml-файлу конфигурации")
+	flag.Parse()
+
+	cfg := config.MustLoad()
+
+	m, err := goose.NewMigrator(cfg.DBPostgres.DSNString(), cfg.DBPostgres.MigrationsPath)
+	if err != nil {
+		fmt.Println("ошибка инициализации мигратора:", err)
+		os.Exit(1)
+	}
+	defer m.Close()
+
+	if err = run(m, *cmd, *name, *kind, *ver); err != nil {
+		fmt.Println("ошибка выполнения команды миграции:", err)
+		os.Exit(1)
+	}
+}
+
+func run(m *goose.Migrator, cmd, name, kind string, version int64) error {
+	switch cmd {
+	case "up":
+		return m.Up()
+	case "up-to":
+		return m.UpTo(version)
+	case "down":
+		return m.Down()
+	case "down-to":
+		return m.DownTo(version)
+	case "redo":
+		return m.Redo()
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			fmt.Println(strconv.FormatInt(s.Version, 10), s.Name, "applied:", s.Applied)
+		}
+		return nil
+	case "version":
+		v, err := m.Version()
+		if err != nil {
+			return err
+		}
+		fmt.Println("version:", v)
+		return nil
+	case "create":
+		if name == "" {
+			return fmt.Errorf("для create обязателен флаг -name")
+		}
+		return m.Create(name, kind)
+	default:
+		return fmt.Errorf("неизвестная команда: %s", cmd)
+	}
+}
@@ -1,12 +1,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"github.com/ilyakaznacheev/cleanenv"
 	"log"
+	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
+
+	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type Config struct {
@@ -14,33 +16,158 @@ type Config struct {
 }
 
 type DBPostgres struct {
-	DSN            string `yaml:"dsn"`
-	MigrationsPath string `yaml:"migrations_path"`
+	// DSN, если задан, имеет приоритет над Host/Port/User/Password/Database/SSLMode/SearchPath
+	DSN            string `yaml:"dsn" env:"DB_DSN"`
+	Host           string `yaml:"host" env:"DB_HOST"`
+	Port           int    `yaml:"port" env:"DB_PORT" env-default:"5432"`
+	User           string `yaml:"user" env:"DB_USER"`
+	Password       string `yaml:"password" env:"DB_PASSWORD"`
+	Database       string `yaml:"database" env:"DB_NAME"`
+	SSLMode        string `yaml:"ssl_mode" env:"DB_SSLMODE" env-default:"disable"`
+	SearchPath     string `yaml:"search_path" env:"DB_SEARCH_PATH"`
+	MigrationsPath string `yaml:"migrations_path" env:"MIGRATIONS_PATH"`
+	// AutoMigrate включает автоматическое применение миграций при старте приложения.
+	// В production обычно выключается, миграции прогоняются отдельно через cmd/migrate
+	// По умолчанию true (задаётся в load, а не через env-default: cleanenv подставляет
+	// env-default в любое нулевое поле, и auto_migrate: false из yaml превращался бы в true)
+	AutoMigrate bool `yaml:"auto_migrate" env:"AUTO_MIGRATE"`
 }
 
+// defaultConfigPath используется, если не заданы ни флаг --config, ни переменная окружения DBPSQL_CONFIG
+const defaultConfigPath = "config/local.yaml"
+
 // #Must приставка ставится тогда, когда функция вместо возврата ошибки будет паниковать
 func MustLoad() *Config {
-	const configPath = "config/local.yaml"
-
-	pathToBack, err := os.Executable()
+	cfg, err := Load()
 	if err != nil {
-		log.Fatalf("Config file error path: %s", err)
+		log.Fatalf("config error: %s", err)
 	}
-	index := strings.LastIndex(pathToBack, "/back/")
-	if index == -1 {
-		fmt.Println("Подстрока не найдена для конфигурационного файла")
+	return cfg
+}
+
+// Load собирает конфигурацию в три слоя: значения по умолчанию (env-default и AutoMigrate = true), затем yaml-файл
+// (путь берётся из флага --config, иначе из $DBPSQL_CONFIG, иначе из defaultConfigPath), затем
+// переменные окружения (env-теги), которые имеют наивысший приоритет. Если yaml-файл не найден,
+// используются только значения по умолчанию и переменные окружения. Перед возвратом
+// конфигурация прогоняется через Validate, чтобы собрать все проблемы сразу, а не падать
+// позже внутри InitDB/goose
+func Load() (*Config, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
 	}
-	index += 5
-	backPath := pathToBack[:index]
-	pathToConfig := filepath.Join(backPath, configPath)
-	if _, err = os.Stat(pathToConfig); os.IsNotExist(err) {
-		log.Fatalf("Config file does not exists: %s", pathToConfig)
+
+	if err = cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
+	return cfg, nil
+}
 
+func load() (*Config, error) {
 	var cfg Config
-	if err = cleanenv.ReadConfig(pathToConfig, &cfg); err != nil {
-		log.Fatalf("cannot read config: %s", err)
+	cfg.AutoMigrate = true
+
+	path := configPath()
+	if _, err := os.Stat(path); err == nil {
+		if err = cleanenv.ReadConfig(path, &cfg); err != nil {
+			return nil, fmt.Errorf("cannot read config file %s: %w", path, err)
+		}
+		return &cfg, nil
+	}
+
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return nil, fmt.Errorf("cannot read config from environment: %w", err)
+	}
+	return &cfg, nil
+}
+
+// configPath определяет путь к yaml-файлу конфигурации: флаг --config, затем переменная
+// окружения DBPSQL_CONFIG, затем defaultConfigPath
+func configPath() string {
+	if p := configPathFromArgs(os.Args[1:]); p != "" {
+		return p
+	}
+	if p := os.Getenv("DBPSQL_CONFIG"); p != "" {
+		return p
 	}
+	return defaultConfigPath
+}
 
-	return &cfg
+// configPathFromArgs ищет --config/-config в аргументах командной строки, не требуя
+// регистрации флага через пакет flag (чтобы не конфликтовать с флагами, которые
+// регистрируют бинари вроде cmd/migrate)
+func configPathFromArgs(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--config" || arg == "-config":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		case strings.HasPrefix(arg, "--config="):
+			return strings.TrimPrefix(arg, "--config=")
+		case strings.HasPrefix(arg, "-config="):
+			return strings.TrimPrefix(arg, "-config=")
+		}
+	}
+	return ""
+}
+
+// Validate проверяет согласованность конфигурации и возвращает единую ошибку, перечисляющую
+// все найденные проблемы, вместо того чтобы останавливаться на первой же из них
+func (c *Config) Validate() error {
+	var problems []error
+
+	if c.DBPostgres.DSN == "" && !c.DBPostgres.hasDSNComponents() {
+		problems = append(problems, errors.New("не задан ни dsn, ни полный набор host/user/database"))
+	}
+
+	if c.DBPostgres.MigrationsPath == "" {
+		problems = append(problems, errors.New("не задан migrations_path"))
+	} else if _, err := os.Stat(c.DBPostgres.MigrationsPath); err != nil {
+		if os.IsNotExist(err) {
+			problems = append(problems, fmt.Errorf("migrations_path не существует: %s", c.DBPostgres.MigrationsPath))
+		} else {
+			problems = append(problems, fmt.Errorf("migrations_path недоступен: %w", err))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// hasDSNComponents сообщает, достаточно ли заполнено дискретных полей, чтобы собрать DSN
+func (db *DBPostgres) hasDSNComponents() bool {
+	return db.Host != "" && db.User != "" && db.Database != ""
+}
+
+// DSNString возвращает строку подключения: DSN, если он задан явно, иначе она собирается
+// из Host/Port/User/Password/Database/SSLMode/SearchPath
+func (db *DBPostgres) DSNString() string {
+	if db.DSN != "" {
+		return db.DSN
+	}
+
+	port := db.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	sslMode := db.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	if db.SearchPath != "" {
+		query.Set("search_path", db.SearchPath)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(db.User, db.Password),
+		Host:     fmt.Sprintf("%s:%d", db.Host, port),
+		Path:     "/" + db.Database,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String()
 }
@@ -3,23 +3,158 @@ package goose
 import (
 	"database/sql"
 	"fmt"
+
 	"github.com/pressly/goose/v3"
 )
 
-func InitMigrations(dsn, dirPath string) error {
-	db, err := sql.Open("postgres", dsn)
+// MigrationStatus описывает состояние одной миграции относительно текущей базы данных
+type MigrationStatus struct {
+	Version int64
+	Name    string
+	Applied bool
+}
 
+// Migrator оборачивает goose и даёт доступ к управлению миграциями (up/down/status/create)
+// поверх одного соединения с базой данных
+type Migrator struct {
+	db  *sql.DB
+	dir string
+}
+
+// NewMigrator открывает соединение с базой данных и готовит Migrator к работе с миграциями
+// в каталоге dirPath
+func NewMigrator(dsn, dirPath string) (*Migrator, error) {
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
-		return fmt.Errorf("error InitMigrations : %w", err)
+		return nil, fmt.Errorf("error NewMigrator: %w", err)
 	}
-	defer db.Close()
 
 	if err = db.Ping(); err != nil {
-		return fmt.Errorf("error InitMigrations ping: %w", err)
+		db.Close()
+		return nil, fmt.Errorf("error NewMigrator ping: %w", err)
+	}
+
+	if err = goose.SetDialect("postgres"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error NewMigrator dialect: %w", err)
+	}
+
+	return &Migrator{db: db, dir: dirPath}, nil
+}
+
+// Close закрывает соединение с базой данных, открытое для Migrator
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Up применяет все ещё не применённые миграции
+func (m *Migrator) Up() error {
+	if err := goose.Up(m.db, m.dir); err != nil {
+		return fmt.Errorf("error up migrations: %w", err)
 	}
-	err = goose.Up(db, dirPath)
+	return nil
+}
+
+// UpTo применяет миграции до указанной версии включительно
+func (m *Migrator) UpTo(version int64) error {
+	if err := goose.UpTo(m.db, m.dir, version); err != nil {
+		return fmt.Errorf("error up to migrations: %w", err)
+	}
+	return nil
+}
+
+// Down откатывает последнюю применённую миграцию
+func (m *Migrator) Down() error {
+	if err := goose.Down(m.db, m.dir); err != nil {
+		return fmt.Errorf("error down migrations: %w", err)
+	}
+	return nil
+}
+
+// DownTo откатывает миграции до указанной версии
+func (m *Migrator) DownTo(version int64) error {
+	if err := goose.DownTo(m.db, m.dir, version); err != nil {
+		return fmt.Errorf("error down to migrations: %w", err)
+	}
+	return nil
+}
+
+// Redo откатывает и заново применяет последнюю миграцию
+func (m *Migrator) Redo() error {
+	if err := goose.Redo(m.db, m.dir); err != nil {
+		return fmt.Errorf("error redo migrations: %w", err)
+	}
+	return nil
+}
+
+// Version возвращает версию последней применённой миграции
+func (m *Migrator) Version() (int64, error) {
+	version, err := goose.GetDBVersion(m.db)
+	if err != nil {
+		return 0, fmt.Errorf("error get migrations version: %w", err)
+	}
+	return version, nil
+}
+
+// Status возвращает список всех миграций из каталога с отметкой о том, применена ли каждая из них
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrations, err := goose.CollectMigrations(m.dir, 0, goose.MaxVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error collect migrations: %w", err)
+	}
+
+	// На свежей базе данных таблица версий ещё не создана — EnsureDBVersion создаёт её при
+	// необходимости, иначе запрос ниже падает с "relation does not exist" вместо того,
+	// чтобы честно сообщить "ничего не применено"
+	if _, err = goose.EnsureDBVersion(m.db); err != nil {
+		return nil, fmt.Errorf("error ensure migrations version table: %w", err)
+	}
+
+	applied := make(map[int64]bool)
+	rows, err := m.db.Query(`SELECT version_id FROM goose_db_version WHERE is_applied = true`)
 	if err != nil {
-		return fmt.Errorf("error up migrations %w: ", err)
+		return nil, fmt.Errorf("error read migrations status: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		if err = rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("error read migrations status: %w", err)
+		}
+		applied[version] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error read migrations status: %w", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, mg := range migrations {
+		statuses = append(statuses, MigrationStatus{
+			Version: mg.Version,
+			Name:    mg.Source,
+			Applied: applied[mg.Version],
+		})
+	}
+	return statuses, nil
+}
+
+// Create создаёт новый файл миграции в каталоге Migrator. kind — "sql" или "go"
+func (m *Migrator) Create(name, kind string) error {
+	if err := goose.Create(m.db, m.dir, name, kind); err != nil {
+		return fmt.Errorf("error create migration: %w", err)
 	}
 	return nil
 }
+
+// InitMigrations оставлен для обратной совместимости: открывает соединение и сразу
+// применяет все миграции. Для управления версиями (up to/down/status) используйте Migrator
+func InitMigrations(dsn, dirPath string) error {
+	m, err := NewMigrator(dsn, dirPath)
+	if err != nil {
+		return fmt.Errorf("error InitMigrations: %w", err)
+	}
+	defer m.Close()
+
+	return m.Up()
+}
@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	e "github.com/TaskGroup/dbpsql/app/back/pkg/models/errors"
+	"github.com/TaskGroup/dbpsql/app/back/pkg/models/template"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxPostgresParams — лимит PostgreSQL на число параметров в одном запросе
+const maxPostgresParams = 65535
+
+// ConflictSpec описывает часть ON CONFLICT (...) DO UPDATE SET ... для BulkUpsert
+type ConflictSpec struct {
+	Columns []string // колонки уникального ограничения, по которому определяется конфликт
+	Update  []string // колонки, которые нужно обновить значениями из EXCLUDED при конфликте
+}
+
+// mapRowsSource адаптирует []map[string]interface{} под pgx.CopyFromSource
+type mapRowsSource struct {
+	rows    []map[string]interface{}
+	columns []string
+	idx     int
+}
+
+func (s *mapRowsSource) Next() bool {
+	s.idx++
+	return s.idx <= len(s.rows)
+}
+
+func (s *mapRowsSource) Values() ([]interface{}, error) {
+	row := s.rows[s.idx-1]
+	values := make([]interface{}, len(s.columns))
+	for i, col := range s.columns {
+		values[i] = row[col]
+	}
+	return values, nil
+}
+
+func (s *mapRowsSource) Err() error {
+	return nil
+}
+
+// BulkInsert вставляет rows в table пачкой одним INSERT ... VALUES (...), (...) RETURNING id,
+// разбитым на части, уважающие лимит PostgreSQL в 65535 параметров на запрос, и всегда
+// возвращает id вставленных строк — в т.ч. внутри транзакции UnitOfWork, где queryer —
+// это *sqlx.Tx.
+//
+// Осознанное отступление от формулировки задачи: там, где driver — pgx, предполагался
+// CopyFrom. COPY физически не поддерживает RETURNING, а эта функция обязана возвращать
+// []int64 — совместить два требования без хрупкого "COPY, затем угадать id отдельным SELECT"
+// нельзя. Поэтому BulkInsert всегда использует VALUES (путь, который единообразно работает
+// и с *sqlx.DB, и с *sqlx.Tx, и всегда возвращает верные id), а CopyFrom вынесен в отдельную
+// явную BulkInsertCopy — для вызовов, которым id не нужны и где нужна максимальная скорость
+func BulkInsert(ctx context.Context, queryer Queryer, table string, columns []string, rows []map[string]interface{}) ([]int64, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return bulkInsertValues(ctx, queryer, table, columns, rows, nil)
+}
+
+// BulkUpsert — то же самое, что BulkInsert, но с ON CONFLICT (...) DO UPDATE SET ...,
+// собранным из conflict. COPY FROM не поддерживает ON CONFLICT, поэтому BulkUpsert всегда
+// использует VALUES-путь
+func BulkUpsert(ctx context.Context, queryer Queryer, table string, columns []string, rows []map[string]interface{}, conflict ConflictSpec) ([]int64, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return bulkInsertValues(ctx, queryer, table, columns, rows, &conflict)
+}
+
+// BulkInsertCopy вставляет rows в table через COPY FROM — самый быстрый способ массовой
+// загрузки в PostgreSQL, но без возврата id, поскольку COPY не поддерживает RETURNING; для
+// этого используйте BulkInsert. Работает только если db открыта поверх драйвера pgx (как её
+// создаёт InitDB) и не участвует в ambient-транзакции UnitOfWork — COPY выполняется на
+// отдельном соединении, взятом из-под db, а не на текущем *sqlx.Tx. table может быть
+// схема-квалифицированным ("schema.table")
+func BulkInsertCopy(ctx context.Context, db *sqlx.DB, table string, columns []string, rows []map[string]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	conn, err := stdlib.AcquireConn(db.DB)
+	if err != nil {
+		return fmt.Errorf("%w: соединение недоступно для COPY (db открыта не через драйвер pgx): %v", e.ErrInternal, err)
+	}
+	defer stdlib.ReleaseConn(db.DB, conn)
+
+	_, err = conn.CopyFrom(ctx, copyTableIdentifier(table), columns, &mapRowsSource{rows: rows, columns: columns})
+	if err != nil {
+		return fmt.Errorf("%w: %v", e.ErrInternal, err)
+	}
+	return nil
+}
+
+// copyTableIdentifier разбирает опционально схема-квалифицированное имя таблицы
+// ("schema.table" или "table") в pgx.Identifier. pgx.Identifier{table} трактует всю строку
+// как один идентификатор, поэтому "public.test_table" без разбора нацелился бы на
+// несуществующую таблицу с буквальной точкой в имени вместо public.test_table
+func copyTableIdentifier(table string) pgx.Identifier {
+	parts := strings.Split(table, ".")
+	ident := make(pgx.Identifier, len(parts))
+	for i, p := range parts {
+		ident[i] = strings.Trim(p, `"`)
+	}
+	return ident
+}
+
+// bulkChunkSize вычисляет, сколько строк с columns колонками каждая помещается в один
+// запрос, не превышая лимит PostgreSQL в maxPostgresParams параметров
+func bulkChunkSize(columns int) (int, error) {
+	if columns <= 0 {
+		return 0, fmt.Errorf("%w: не заданы колонки для вставки", e.ErrInternal)
+	}
+	chunkSize := maxPostgresParams / columns
+	if chunkSize == 0 {
+		return 0, fmt.Errorf("%w: слишком много колонок для одной строки", e.ErrInternal)
+	}
+	return chunkSize, nil
+}
+
+// bulkInsertValues вставляет rows одним INSERT ... VALUES (...), (...) [ON CONFLICT ...]
+// RETURNING id, разбивая rows на части так, чтобы ни одна из них не превышала лимит
+// PostgreSQL на число параметров в запросе
+func bulkInsertValues(ctx context.Context, queryer Queryer, table string, columns []string, rows []map[string]interface{}, conflict *ConflictSpec) ([]int64, error) {
+	chunkSize, err := bulkChunkSize(len(columns))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		chunkIds, err := execBulkValuesChunk(ctx, queryer, table, columns, rows[start:end], conflict)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, chunkIds...)
+	}
+	return ids, nil
+}
+
+// execBulkValuesChunk выполняет вставку одного чанка строк и возвращает их id
+func execBulkValuesChunk(ctx context.Context, queryer Queryer, table string, columns []string, rows []map[string]interface{}, conflict *ConflictSpec) ([]int64, error) {
+	query, params := buildBulkValuesQuery(table, columns, rows, conflict)
+
+	var res []template.OnlyId
+	if err := QueryMultiple(ctx, queryer, query, params, &res); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(res))
+	for i, r := range res {
+		ids[i] = r.Id
+	}
+	return ids, nil
+}
+
+// buildBulkValuesQuery собирает именованный INSERT ... VALUES (...), (...) [ON CONFLICT ...]
+// RETURNING id и карту параметров к нему. Параметры каждой строки получают уникальный
+// префикс (r0_, r1_, ...), поскольку PrepareNamedContext не различает параметры по строкам
+func buildBulkValuesQuery(table string, columns []string, rows []map[string]interface{}, conflict *ConflictSpec) (string, map[string]interface{}) {
+	params := make(map[string]interface{}, len(rows)*len(columns))
+	valueGroups := make([]string, len(rows))
+
+	for i, row := range rows {
+		placeholders := make([]string, len(columns))
+		for j, col := range columns {
+			key := fmt.Sprintf("r%d_%s", i, col)
+			placeholders[j] = ":" + key
+			params[key] = row[col]
+		}
+		valueGroups[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, strings.Join(columns, ", "), strings.Join(valueGroups, ", "))
+	if conflict != nil {
+		setClauses := make([]string, len(conflict.Update))
+		for i, col := range conflict.Update {
+			setClauses[i] = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+		}
+		query += fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(conflict.Columns, ", "), strings.Join(setClauses, ", "))
+	}
+	query += " RETURNING id"
+
+	return query, params
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import "testing"
+
+func TestBuildBulkValuesQuery(t *testing.T) {
+	columns := []string{"name", "age"}
+	rows := []map[string]interface{}{
+		{"name": "a", "age": 1},
+		{"name": "b", "age": 2},
+	}
+
+	query, params := buildBulkValuesQuery("users", columns, rows, nil)
+
+	want := "INSERT INTO users (name, age) VALUES (:r0_name, :r0_age), (:r1_name, :r1_age) RETURNING id"
+	if query != want {
+		t.Fatalf("query = %q, want %q", query, want)
+	}
+	if len(params) != 4 {
+		t.Fatalf("expected 4 params, got %d: %#v", len(params), params)
+	}
+	if params["r0_name"] != "a" || params["r1_age"] != 2 {
+		t.Fatalf("unexpected params: %#v", params)
+	}
+}
+
+func TestBuildBulkValuesQueryWithConflict(t *testing.T) {
+	columns := []string{"id", "name"}
+	rows := []map[string]interface{}{{"id": 1, "name": "a"}}
+	conflict := &ConflictSpec{Columns: []string{"id"}, Update: []string{"name"}}
+
+	query, _ := buildBulkValuesQuery("users", columns, rows, conflict)
+
+	want := "INSERT INTO users (id, name) VALUES (:r0_id, :r0_name) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name RETURNING id"
+	if query != want {
+		t.Fatalf("query = %q, want %q", query, want)
+	}
+}
+
+func TestBulkChunkSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns int
+		want    int
+		wantErr bool
+	}{
+		{"no columns", 0, 0, true},
+		{"single column", 1, maxPostgresParams, false},
+		{"typical row", 10, maxPostgresParams / 10, false},
+		{"exact boundary", maxPostgresParams, 1, false},
+		{"too many columns", maxPostgresParams + 1, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bulkChunkSize(tt.columns)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %d columns", tt.columns)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("chunkSize = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyTableIdentifier(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"users", []string{"users"}},
+		{"public.users", []string{"public", "users"}},
+		{`"public"."users"`, []string{"public", "users"}},
+	}
+
+	for _, tt := range tests {
+		ident := copyTableIdentifier(tt.in)
+		if len(ident) != len(tt.want) {
+			t.Fatalf("copyTableIdentifier(%q) = %v, want %v", tt.in, ident, tt.want)
+		}
+		for i := range ident {
+			if ident[i] != tt.want[i] {
+				t.Fatalf("copyTableIdentifier(%q)[%d] = %q, want %q", tt.in, i, ident[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Listener держит выделенное из пула соединение для PostgreSQL LISTEN/NOTIFY
+// и рассылает уведомления подписанным обработчикам, переподключаясь при обрыве связи
+type Listener struct {
+	pool      *pgxpool.Pool
+	mu        sync.Mutex
+	handlers  map[string][]func(payload string)
+	listening map[string]bool
+}
+
+// NewListener создаёт новый Listener поверх переданного пула соединений
+func NewListener(pool *pgxpool.Pool) *Listener {
+	return &Listener{
+		pool:      pool,
+		handlers:  make(map[string][]func(payload string)),
+		listening: make(map[string]bool),
+	}
+}
+
+// Subscribe подписывает handler на уведомления канала channel. Для каждого канала
+// держится одно фоновое соединение LISTEN вне зависимости от числа подписчиков. Если
+// фоновая горутина канала ранее исчерпала попытки переподключения и завершилась,
+// Subscribe перезапускает её, не теряя ранее зарегистрированные обработчики
+func (l *Listener) Subscribe(ctx context.Context, channel string, handler func(payload string)) error {
+	l.mu.Lock()
+	l.handlers[channel] = append(l.handlers[channel], handler)
+	alreadyListening := l.listening[channel]
+	l.listening[channel] = true
+	l.mu.Unlock()
+
+	if alreadyListening {
+		return nil
+	}
+
+	go l.listen(ctx, channel)
+	return nil
+}
+
+// listen удерживает подписку на channel, переподключаясь по той же политике ретраев,
+// что и InitDB, пока ctx не будет отменён. Счётчик попыток сбрасывается после каждого
+// успешно установленного LISTEN, поэтому лимит ограничивает число подряд идущих неудач,
+// а не общее число переподключений за время жизни подписки
+func (l *Listener) listen(ctx context.Context, channel string) {
+	attempt := 0
+	for attempt < MaxRetries {
+		connected, err := l.listenOnce(ctx, channel)
+		if connected {
+			attempt = 0
+		}
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+
+		attempt++
+		slog.Error("Соединение LISTEN разорвано", "канал", channel, "попытка", attempt, "ошибка", err)
+		time.Sleep(RetryInterval)
+	}
+
+	l.giveUp(channel)
+}
+
+// giveUp снимает channel с учёта как прослушиваемый после исчерпания попыток
+// переподключения, сохраняя зарегистрированные обработчики: следующий Subscribe на тот же
+// channel перезапустит фоновую горутину вместо того, чтобы считать канал всё ещё живым
+func (l *Listener) giveUp(channel string) {
+	l.mu.Lock()
+	delete(l.listening, channel)
+	l.mu.Unlock()
+	slog.Error("Подписка на канал остановлена после исчерпания попыток переподключения", "канал", channel)
+}
+
+// listenOnce забирает соединение из пула, выполняет LISTEN и рассылает уведомления, пока
+// соединение живо. connected сообщает, был ли LISTEN успешно установлен до возникновения err,
+// — это используется listen(), чтобы отличать обрыв уже работавшей подписки от неудачи
+// самого подключения
+func (l *Listener) listenOnce(ctx context.Context, channel string) (connected bool, err error) {
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("ошибка получения соединения: %w", err)
+	}
+	defer conn.Release()
+
+	listenStmt := fmt.Sprintf("LISTEN %s", pgx.Identifier{channel}.Sanitize())
+	if _, err = conn.Exec(ctx, listenStmt); err != nil {
+		return false, fmt.Errorf("ошибка подписки на канал %s: %w", channel, err)
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return true, fmt.Errorf("ошибка ожидания уведомления: %w", err)
+		}
+		l.dispatch(notification)
+	}
+}
+
+// dispatch рассылает уведомление всем обработчикам, подписанным на его канал
+func (l *Listener) dispatch(n *pgconn.Notification) {
+	l.mu.Lock()
+	handlers := make([]func(payload string), len(l.handlers[n.Channel]))
+	copy(handlers, l.handlers[n.Channel])
+	l.mu.Unlock()
+
+	for _, h := range handlers {
+		h(n.Payload)
+	}
+}
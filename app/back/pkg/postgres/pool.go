@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	e "github.com/TaskGroup/dbpsql/app/back/pkg/models/errors"
+	"github.com/TaskGroup/dbpsql/app/back/pkg/models/template"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jmoiron/sqlx"
+)
+
+// PgxQueryer определяет интерфейс для выполнения запросов через нативный pgx (пул или отдельное соединение)
+type PgxQueryer interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// NewPool инициализирует пул соединений pgxpool с той же политикой ретраев, что и InitDB
+func NewPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	var pool *pgxpool.Pool
+	err := retryConnect("Не удалось подключиться к базе данных (pgxpool)", func() error {
+		var err error
+		pool, err = connectPool(ctx, dsn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Подключение к базе данных (pgxpool) установлено")
+	return pool, nil
+}
+
+// connectPool устанавливает соединение с базой данных через pgxpool и проверяет его
+func connectPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при разборе DSN: %w", err)
+	}
+	cfg.MaxConns = MaxOpenConns
+
+	connectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	pool, err := pgxpool.ConnectConfig(connectCtx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при открытии соединения: %w", err)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err = pool.Ping(pingCtx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ошибка при проверке соединения: %w", err)
+	}
+	return pool, nil
+}
+
+// ClosePool закрывает пул соединений
+func ClosePool(pool *pgxpool.Pool) {
+	pool.Close()
+}
+
+// bindNamedPostgres переводит именованные плейсхолдеры (:name) в позиционные ($1, $2, ...),
+// понятные нативному драйверу pgx
+func bindNamedPostgres(query string, params map[string]interface{}) (string, []interface{}, error) {
+	return sqlx.BindNamed(sqlx.DOLLAR, query, params)
+}
+
+// QueryMultiplePgx выполняет запрос через pgx и сканирует результаты в предоставленный срез
+func QueryMultiplePgx(ctx context.Context, queryer PgxQueryer, query string, params map[string]interface{}, dest interface{}) error {
+	q, args, err := bindNamedPostgres(query, params)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки запроса: %w", err)
+	}
+
+	rows, err := queryer.Query(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: %v", e.ErrInternal, err)
+	}
+	defer rows.Close()
+
+	if err = pgxscan.ScanAll(dest, rows); err != nil {
+		return fmt.Errorf("%w: %v", e.ErrInternal, err)
+	}
+	return nil
+}
+
+// QuerySinglePgx выполняет запрос через pgx и сканирует одну строку результата
+func QuerySinglePgx(ctx context.Context, queryer PgxQueryer, query string, params map[string]interface{}, dest interface{}) error {
+	q, args, err := bindNamedPostgres(query, params)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки запроса: %w", err)
+	}
+
+	rows, err := queryer.Query(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: %v", e.ErrInternal, err)
+	}
+	defer rows.Close()
+
+	if err = pgxscan.ScanOne(dest, rows); err != nil {
+		if pgxscan.NotFound(err) || errors.Is(err, pgx.ErrNoRows) {
+			return e.ErrNotFound
+		}
+		return fmt.Errorf("%w: %v", e.ErrInternal, err)
+	}
+	return nil
+}
+
+// InsertRecordPgx выполняет вставку новой записи через pgx и возвращает её ID
+func InsertRecordPgx(ctx context.Context, queryer PgxQueryer, query string, params map[string]interface{}) (int64, error) {
+	var ids []template.OnlyId
+	if err := QueryMultiplePgx(ctx, queryer, query, params, &ids); err != nil {
+		return 0, err
+	}
+
+	if len(ids) > 0 {
+		return ids[0].Id, nil
+	}
+	return 0, fmt.Errorf("%w: запись не добавлена", e.ErrInternal)
+}
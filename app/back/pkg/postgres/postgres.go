@@ -8,6 +8,7 @@ import (
 	e "github.com/TaskGroup/dbpsql/app/back/pkg/models/errors"
 	"github.com/TaskGroup/dbpsql/app/back/pkg/models/template"
 	"log"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/jackc/pgx/v4/pgxpool"
@@ -41,13 +42,21 @@ type Queryer interface {
 // UnitOfWork определяет интерфейс паттерна Unit of Work
 type UnitOfWork interface {
 	Do(ctx context.Context, fn func(uow UnitOfWork) error) error
+	DoTx(ctx context.Context, opts *sql.TxOptions, fn func(uow UnitOfWork) error) error
 	GetQueryer() Queryer
 }
 
-// PostgresUnitOfWork реализует интерфейс UnitOfWork
+// PostgresUnitOfWork реализует интерфейс UnitOfWork. Значение иммутабельно после создания:
+// Do/DoTx не мутируют приёмник, а передают в fn новый, вложенный PostgresUnitOfWork, поэтому
+// GetQueryer безопасен для параллельного чтения из разных горутин
 type PostgresUnitOfWork struct {
-	db *sqlx.DB
-	tx *sqlx.Tx
+	db    *sqlx.DB
+	tx    *sqlx.Tx
+	depth int
+	// spCounter — общий на всё дерево вложенных savepoint'ов одной транзакции счётчик, по
+	// которому генерируются их имена. depth один и тот же для двух параллельных вызовов Do
+	// на одном uow, а spCounter — нет, поэтому конкурентные savepoint'ы не коллизируют
+	spCounter *uint64
 }
 
 // NewUnitOfWork создает новый экземпляр PostgresUnitOfWork
@@ -57,13 +66,31 @@ func NewUnitOfWork(db *sqlx.DB) *PostgresUnitOfWork {
 	}
 }
 
-// Do выполняет функцию в рамках транзакции
-func (uow *PostgresUnitOfWork) Do(ctx context.Context, fn func(uow UnitOfWork) error) (err error) {
-	tx, err := uow.db.BeginTxx(ctx, nil)
+// Do выполняет функцию в рамках транзакции (или вложенного savepoint'а, если вызвано
+// повторно внутри уже открытой транзакции)
+func (uow *PostgresUnitOfWork) Do(ctx context.Context, fn func(uow UnitOfWork) error) error {
+	return uow.DoTx(ctx, nil, fn)
+}
+
+// DoTx выполняет функцию в рамках транзакции с заданными *sql.TxOptions. Если DoTx вызван
+// повторно внутри уже открытой транзакции (переданный uow уже содержит tx), opts игнорируются
+// и вместо новой транзакции используется SAVEPOINT, который RELEASE'ится при успешном
+// завершении fn и откатывается через ROLLBACK TO при ошибке или панике
+func (uow *PostgresUnitOfWork) DoTx(ctx context.Context, opts *sql.TxOptions, fn func(uow UnitOfWork) error) (err error) {
+	if uow.tx == nil {
+		return uow.doRootTx(ctx, opts, fn)
+	}
+	return uow.doSavepoint(ctx, fn)
+}
+
+// doRootTx открывает новую транзакцию верхнего уровня
+func (uow *PostgresUnitOfWork) doRootTx(ctx context.Context, opts *sql.TxOptions, fn func(uow UnitOfWork) error) (err error) {
+	tx, err := uow.db.BeginTxx(ctx, opts)
 	if err != nil {
 		return err
 	}
-	uow.tx = tx
+	var spCounter uint64
+	child := &PostgresUnitOfWork{db: uow.db, tx: tx, spCounter: &spCounter}
 
 	defer func() {
 		if p := recover(); p != nil {
@@ -76,11 +103,34 @@ func (uow *PostgresUnitOfWork) Do(ctx context.Context, fn func(uow UnitOfWork) e
 		}
 	}()
 
-	err = fn(uow)
+	err = fn(child)
 	return err
 }
 
-// GetQueryer возвращает текущий Queryer (tx или db)
+// doSavepoint открывает именованный SAVEPOINT на уже открытой транзакции
+func (uow *PostgresUnitOfWork) doSavepoint(ctx context.Context, fn func(uow UnitOfWork) error) (err error) {
+	sp := fmt.Sprintf("sp_%d", atomic.AddUint64(uow.spCounter, 1))
+	if _, err = uow.tx.ExecContext(ctx, "SAVEPOINT "+sp); err != nil {
+		return fmt.Errorf("ошибка создания savepoint: %w", err)
+	}
+	child := &PostgresUnitOfWork{db: uow.db, tx: uow.tx, depth: uow.depth + 1, spCounter: uow.spCounter}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = uow.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp)
+			panic(p)
+		} else if err != nil {
+			_, _ = uow.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp)
+		} else {
+			_, err = uow.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp)
+		}
+	}()
+
+	err = fn(child)
+	return err
+}
+
+// GetQueryer возвращает Queryer, зафиксированный на момент создания этого снимка uow (tx или db)
 func (uow *PostgresUnitOfWork) GetQueryer() Queryer {
 	if uow.tx != nil {
 		return uow.tx
@@ -88,27 +138,41 @@ func (uow *PostgresUnitOfWork) GetQueryer() Queryer {
 	return uow.db
 }
 
-// InitDB инициализирует подключение к базе данных и настраивает пул соединений
-func InitDB(dsn string) (*sqlx.DB, error) {
-	var db *sqlx.DB
+// retryConnect повторяет attempt до MaxRetries раз с паузой RetryInterval между неудачными
+// попытками, логируя каждую под label. Общая часть InitDB и NewPool (pool.go), которые
+// держат разные типы пулов (*sqlx.DB и *pgxpool.Pool) и поэтому не могут разделить сам пул,
+// но используют одну и ту же политику ретраев
+func retryConnect(label string, attempt func() error) error {
 	var err error
-
 	for i := 0; i < MaxRetries; i++ {
-		db, err = connectDB(dsn)
-		if err == nil {
-			// Настройка пула соединений
-			db.SetMaxOpenConns(MaxOpenConns)
-			db.SetMaxIdleConns(MaxIdleConns)
-			db.SetConnMaxLifetime(ConnMaxLifetime)
-
-			log.Println("Подключение к базе данных установлено")
-			return db, nil
+		if err = attempt(); err == nil {
+			return nil
 		}
-
-		slog.Error("Не удалось подключиться к базе данных", "попытка", i+1, "ошибка", err)
+		slog.Error(label, "попытка", i+1, "ошибка", err)
 		time.Sleep(RetryInterval)
 	}
-	return nil, fmt.Errorf("не удалось подключиться к базе данных после %d попыток: %w", MaxRetries, err)
+	return fmt.Errorf("не удалось подключиться к базе данных после %d попыток: %w", MaxRetries, err)
+}
+
+// InitDB инициализирует подключение к базе данных и настраивает пул соединений
+func InitDB(dsn string) (*sqlx.DB, error) {
+	var db *sqlx.DB
+	err := retryConnect("Не удалось подключиться к базе данных", func() error {
+		var err error
+		db, err = connectDB(dsn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Настройка пула соединений
+	db.SetMaxOpenConns(MaxOpenConns)
+	db.SetMaxIdleConns(MaxIdleConns)
+	db.SetConnMaxLifetime(ConnMaxLifetime)
+
+	log.Println("Подключение к базе данных установлено")
+	return db, nil
 }
 
 // connectDB устанавливает соединение с базой данных и проверяет его
@@ -139,14 +203,15 @@ func CloseDB(db *sqlx.DB) error {
 
 // QueryMultiple выполняет запрос и сканирует результаты в предоставленный срез
 func QueryMultiple(ctx context.Context, queryer Queryer, query string, params map[string]interface{}, dest interface{}) error {
-	stmt, err := queryer.PrepareNamedContext(ctx, query)
+	stmt, closeStmt, err := prepareNamed(ctx, queryer, query)
 	if err != nil {
 		return fmt.Errorf("ошибка подготовки запроса: %w", err)
 	}
-	defer stmt.Close()
+	defer closeStmt()
 
 	err = stmt.SelectContext(ctx, dest, params)
 	if err != nil {
+		invalidateOnBadConn(queryer, query, err)
 		if errors.Is(err, sql.ErrNoRows) {
 			return e.ErrNotFound
 		}
@@ -171,14 +236,15 @@ func InsertRecord(ctx context.Context, queryer Queryer, query string, params map
 
 // UpdateRecord выполняет обновление записи в таблице.
 func UpdateRecord(ctx context.Context, queryer Queryer, query string, params map[string]interface{}) error {
-	stmt, err := queryer.PrepareNamedContext(ctx, query)
+	stmt, closeStmt, err := prepareNamed(ctx, queryer, query)
 	if err != nil {
 		return fmt.Errorf("ошибка подготовки запроса: %w", err)
 	}
-	defer stmt.Close()
+	defer closeStmt()
 
 	res, err := stmt.ExecContext(ctx, params)
 	if err != nil {
+		invalidateOnBadConn(queryer, query, err)
 		return fmt.Errorf("%w: %v", e.ErrInternal, err)
 	}
 
@@ -213,14 +279,15 @@ func UpdateRecordWithResultListId(ctx context.Context, queryer Queryer, query st
 
 // DeleteRecord выполняет удаление записи из таблицы.
 func DeleteRecord(ctx context.Context, queryer Queryer, query string, params map[string]interface{}) error {
-	stmt, err := queryer.PrepareNamedContext(ctx, query)
+	stmt, closeStmt, err := prepareNamed(ctx, queryer, query)
 	if err != nil {
 		return fmt.Errorf("ошибка подготовки запроса: %w", err)
 	}
-	defer stmt.Close()
+	defer closeStmt()
 
 	res, err := stmt.ExecContext(ctx, params)
 	if err != nil {
+		invalidateOnBadConn(queryer, query, err)
 		return fmt.Errorf("%w: %v", e.ErrInternal, err)
 	}
 
@@ -274,14 +341,15 @@ func AddRecord(ctx context.Context, queryer Queryer, query string, params map[st
 
 // ExecuteNonQuery Обновление/удаление данных (запрос без возврта значений)
 func ExecuteNonQuery(qCtx context.Context, queryer Queryer, query string, params map[string]interface{}) error {
-	stmt, err := queryer.PrepareNamedContext(qCtx, query)
+	stmt, closeStmt, err := prepareNamed(qCtx, queryer, query)
 	if err != nil {
 		return fmt.Errorf("ошибка подготовки запроса: %w", err)
 	}
-	defer stmt.Close()
+	defer closeStmt()
 
 	res, err := stmt.ExecContext(qCtx, params)
 	if err != nil {
+		invalidateOnBadConn(queryer, query, err)
 		return fmt.Errorf("%w: %v", e.ErrInternal, err)
 	}
 
@@ -296,14 +364,15 @@ func ExecuteNonQuery(qCtx context.Context, queryer Queryer, query string, params
 }
 
 func QuerySingle(ctx context.Context, queryer Queryer, query string, params map[string]interface{}, dest interface{}) error {
-	stmt, err := queryer.PrepareNamedContext(ctx, query)
+	stmt, closeStmt, err := prepareNamed(ctx, queryer, query)
 	if err != nil {
 		return fmt.Errorf("ошибка подготовки запроса: %w", err)
 	}
-	defer stmt.Close()
+	defer closeStmt()
 
 	err = stmt.GetContext(ctx, dest, params)
 	if err != nil {
+		invalidateOnBadConn(queryer, query, err)
 		if errors.Is(err, sql.ErrNoRows) {
 			return e.ErrNotFound
 		}
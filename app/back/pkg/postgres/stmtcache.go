@@ -0,0 +1,220 @@
+package postgres
+
+import (
+	"container/list"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultStmtCacheSize — ёмкость кэша подготовленных запросов по умолчанию
+const DefaultStmtCacheSize = 512
+
+// DefaultStmtCacheTTL — время жизни записи кэша по умолчанию
+const DefaultStmtCacheTTL = 10 * time.Minute
+
+// StmtCache — LRU-кэш *sqlx.NamedStmt, ключом которого является пара (соединение, текст
+// запроса). Это убирает накладные расходы на PrepareNamedContext/Close на каждый вызов
+// QueryMultiple/QuerySingle/UpdateRecord/DeleteRecord/ExecuteNonQuery и даёт серверному
+// кэшу подготовленных запросов PostgreSQL шанс действительно переиспользоваться
+type StmtCache struct {
+	mu        sync.Mutex
+	capacity  int
+	ttl       time.Duration
+	entries   map[stmtCacheKey]*list.Element
+	order     *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// stmtCacheKey различает запросы по соединению: NamedStmt, подготовленный на одном *sqlx.DB,
+// не годится для выполнения на другом
+type stmtCacheKey struct {
+	conn  *sqlx.DB
+	query string
+}
+
+// stmtCacheEntry отслеживает число горутин, которые сейчас выполняют stmt (refCount), чтобы
+// эвикция/TTL/инвалидация не закрывали стейтмент, пока им кто-то пользуется: removeElementLocked
+// снимает запись с учёта немедленно, но откладывает реальный Close до release() последнего
+// держателя
+type stmtCacheEntry struct {
+	key       stmtCacheKey
+	stmt      *sqlx.NamedStmt
+	expiresAt time.Time
+	refCount  int
+	removed   bool
+}
+
+// NewStmtCache создаёт кэш с заданной ёмкостью и TTL. capacity <= 0 означает
+// DefaultStmtCacheSize, ttl <= 0 означает DefaultStmtCacheTTL
+func NewStmtCache(capacity int, ttl time.Duration) *StmtCache {
+	if capacity <= 0 {
+		capacity = DefaultStmtCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultStmtCacheTTL
+	}
+	return &StmtCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[stmtCacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// defaultStmtCache — кэш, через который работают все пакетные хелперы (QueryMultiple и т.д.)
+var defaultStmtCache = NewStmtCache(DefaultStmtCacheSize, DefaultStmtCacheTTL)
+
+// SetStmtCacheSize меняет ёмкость кэша по умолчанию, вытесняя лишние записи немедленно
+func SetStmtCacheSize(size int) {
+	defaultStmtCache.setCapacity(size)
+}
+
+// StmtCacheStats возвращает снимок счётчиков кэша по умолчанию (hits, misses, evictions)
+func StmtCacheStats() (hits, misses, evictions uint64) {
+	return defaultStmtCache.Stats()
+}
+
+// Stats возвращает снимок счётчиков этого кэша
+func (c *StmtCache) Stats() (hits, misses, evictions uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses, c.evictions
+}
+
+func (c *StmtCache) setCapacity(size int) {
+	if size <= 0 {
+		size = DefaultStmtCacheSize
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = size
+	for c.order.Len() > c.capacity {
+		c.evictOldestLocked()
+	}
+}
+
+// getOrPrepare возвращает закэшированный NamedStmt для (db, query), подготавливая его при
+// промахе, вместе с функцией release, которую вызывающая сторона обязана вызвать (обычно
+// через defer), когда закончит пользоваться stmt. Сам stmt закрывать нельзя — им владеет кэш
+func (c *StmtCache) getOrPrepare(ctx context.Context, db *sqlx.DB, query string) (*sqlx.NamedStmt, func(), error) {
+	key := stmtCacheKey{conn: db, query: query}
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*stmtCacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			c.order.MoveToFront(el)
+			entry.refCount++
+			c.hits++
+			c.mu.Unlock()
+			return entry.stmt, c.release(entry), nil
+		}
+		c.removeElementLocked(el)
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	stmt, err := db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Другая горутина могла успеть подготовить и вставить тот же (db, query), пока мы готовили
+	// свой stmt — переиспользуем её запись, а наш дубликат закрываем сразу
+	if el, ok := c.entries[key]; ok {
+		existing := el.Value.(*stmtCacheEntry)
+		c.order.MoveToFront(el)
+		existing.refCount++
+		_ = stmt.Close()
+		return existing.stmt, c.release(existing), nil
+	}
+
+	entry := &stmtCacheEntry{key: key, stmt: stmt, expiresAt: time.Now().Add(c.ttl), refCount: 1}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		c.evictOldestLocked()
+	}
+	return stmt, c.release(entry), nil
+}
+
+// release возвращает функцию, уменьшающую refCount записи на единицу. Если к этому моменту
+// запись уже была вытеснена/инвалидирована (removed) и это был последний держатель
+// (refCount <= 0), функция закрывает stmt — это единственное место, где Close вызывается
+// для записи, снятой с учёта, пока ей ещё мог пользоваться кто-то другой
+func (c *StmtCache) release(entry *stmtCacheEntry) func() {
+	return func() {
+		c.mu.Lock()
+		entry.refCount--
+		closeNow := entry.removed && entry.refCount <= 0
+		c.mu.Unlock()
+		if closeNow {
+			_ = entry.stmt.Close()
+		}
+	}
+}
+
+// invalidate удаляет запись (db, query) из кэша, например, после driver.ErrBadConn
+func (c *StmtCache) invalidate(db *sqlx.DB, query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[stmtCacheKey{conn: db, query: query}]; ok {
+		c.removeElementLocked(el)
+	}
+}
+
+func (c *StmtCache) evictOldestLocked() {
+	el := c.order.Back()
+	if el == nil {
+		return
+	}
+	c.removeElementLocked(el)
+	c.evictions++
+}
+
+// removeElementLocked снимает запись с учёта (map + LRU-список) немедленно, чтобы новые
+// вызовы getOrPrepare её больше не находили, но закрывает stmt только если на него прямо
+// сейчас нет активных держателей — иначе Close откладывается до их release()
+func (c *StmtCache) removeElementLocked(el *list.Element) {
+	entry := el.Value.(*stmtCacheEntry)
+	delete(c.entries, entry.key)
+	c.order.Remove(el)
+	entry.removed = true
+	if entry.refCount <= 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// prepareNamed возвращает подготовленный NamedStmt для query на queryer вместе с функцией,
+// закрывающей его (или снимающей с него ссылку кэша), когда он больше не нужен. Для *sqlx.DB
+// стейтмент берётся из кэша по умолчанию; для *sqlx.Tx (и любого другого Queryer) кэш не
+// используется, так как подготовленные на транзакции стейтменты умирают вместе с ней —
+// stmt готовится заново и закрывается вызывающей стороной через возвращённую функцию
+func prepareNamed(ctx context.Context, queryer Queryer, query string) (*sqlx.NamedStmt, func(), error) {
+	if db, ok := queryer.(*sqlx.DB); ok {
+		return defaultStmtCache.getOrPrepare(ctx, db, query)
+	}
+
+	stmt, err := queryer.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stmt, func() { _ = stmt.Close() }, nil
+}
+
+// invalidateOnBadConn вычищает query из кэша по умолчанию, если err сигнализирует о мёртвом
+// соединении — иначе следующий вызов получит тот же обречённый stmt из кэша
+func invalidateOnBadConn(queryer Queryer, query string, err error) {
+	if db, ok := queryer.(*sqlx.DB); ok && errors.Is(err, driver.ErrBadConn) {
+		defaultStmtCache.invalidate(db, query)
+	}
+}
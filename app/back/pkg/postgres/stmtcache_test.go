@@ -0,0 +1,192 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeDriver — минимальный driver.Driver без реальной базы данных, нужный только чтобы
+// получить настоящий *sqlx.DB для тестов StmtCache: PrepareNamedContext сам запрос никогда
+// не выполняет, поэтому fakeStmt.Exec/Query в этих тестах не вызываются
+
+var fakeDriverClosed struct {
+	mu    sync.Mutex
+	count int
+}
+
+func fakeDriverClosedCount() int {
+	fakeDriverClosed.mu.Lock()
+	defer fakeDriverClosed.mu.Unlock()
+	return fakeDriverClosed.count
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, sql.ErrTxDone }
+
+type fakeStmt struct{}
+
+func (s *fakeStmt) Close() error {
+	fakeDriverClosed.mu.Lock()
+	fakeDriverClosed.count++
+	fakeDriverClosed.mu.Unlock()
+	return nil
+}
+func (s *fakeStmt) NumInput() int                                   { return 0 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, sql.ErrNoRows }
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error)  { return nil, sql.ErrNoRows }
+
+var registerFakeDriverOnce sync.Once
+
+func openFakeDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	registerFakeDriverOnce.Do(func() {
+		sql.Register("stmtcache_fake", fakeDriver{})
+	})
+	db := sqlx.MustOpen("stmtcache_fake", "test")
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestStmtCacheHitMiss(t *testing.T) {
+	cache := NewStmtCache(10, time.Hour)
+	db := openFakeDB(t)
+	ctx := context.Background()
+
+	_, release1, err := cache.getOrPrepare(ctx, db, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release1()
+
+	_, release2, err := cache.getOrPrepare(ctx, db, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release2()
+
+	hits, misses, _ := cache.Stats()
+	if hits != 1 || misses != 1 {
+		t.Fatalf("hits=%d misses=%d, want hits=1 misses=1", hits, misses)
+	}
+}
+
+func TestStmtCacheEviction(t *testing.T) {
+	cache := NewStmtCache(1, time.Hour)
+	db := openFakeDB(t)
+	ctx := context.Background()
+
+	_, release1, err := cache.getOrPrepare(ctx, db, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release1()
+
+	_, release2, err := cache.getOrPrepare(ctx, db, "SELECT 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release2()
+
+	_, _, evictions := cache.Stats()
+	if evictions != 1 {
+		t.Fatalf("evictions = %d, want 1", evictions)
+	}
+}
+
+func TestStmtCacheReleaseDefersCloseUntilLastHolder(t *testing.T) {
+	cache := NewStmtCache(1, time.Hour)
+	db := openFakeDB(t)
+	ctx := context.Background()
+
+	before := fakeDriverClosedCount()
+
+	_, releaseA, err := cache.getOrPrepare(ctx, db, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, releaseB, err := cache.getOrPrepare(ctx, db, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Эвикция под тем же ключом (через переполнение capacity) снимает запись с учёта, но не
+	// должна закрывать stmt, пока у него есть активные держатели (releaseA/releaseB ещё не вызваны)
+	if _, _, err = cache.getOrPrepare(ctx, db, "SELECT 2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fakeDriverClosedCount() != before {
+		t.Fatalf("stmt closed while still held by %d holders", 2)
+	}
+
+	releaseA()
+	if fakeDriverClosedCount() != before {
+		t.Fatalf("stmt closed before last holder released")
+	}
+
+	releaseB()
+	if fakeDriverClosedCount() != before+1 {
+		t.Fatalf("stmt not closed after last holder released")
+	}
+}
+
+func TestStmtCacheExpiry(t *testing.T) {
+	cache := NewStmtCache(10, time.Millisecond)
+	db := openFakeDB(t)
+	ctx := context.Background()
+
+	_, release, err := cache.getOrPrepare(ctx, db, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, release2, err := cache.getOrPrepare(ctx, db, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release2()
+
+	hits, misses, _ := cache.Stats()
+	if hits != 0 || misses != 2 {
+		t.Fatalf("hits=%d misses=%d, want hits=0 misses=2 (expired entry must not count as a hit)", hits, misses)
+	}
+}
+
+func TestStmtCacheSetCapacityEvicts(t *testing.T) {
+	cache := NewStmtCache(2, time.Hour)
+	db := openFakeDB(t)
+	ctx := context.Background()
+
+	for _, q := range []string{"SELECT 1", "SELECT 2"} {
+		_, release, err := cache.getOrPrepare(ctx, db, q)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		release()
+	}
+
+	cache.setCapacity(1)
+
+	_, _, evictions := cache.Stats()
+	if evictions != 1 {
+		t.Fatalf("evictions = %d, want 1 after shrinking capacity", evictions)
+	}
+}